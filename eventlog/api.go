@@ -78,6 +78,26 @@ func (c *Client) Log(ev ...Event) ([]LogID, error) {
 	return out, nil
 }
 
+// Search asks the service for the events on req.Topic with a timestamp
+// between req.From and req.To (both inclusive), in ascending timestamp
+// order; if req.Limit is positive, only the first req.Limit of them are
+// returned.
+func (c *Client) Search(req *SearchRequest) (*SearchResponse, error) {
+	reply := &SearchResponse{}
+	if err := c.SendProtobuf(c.roster.List[0], req, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// GetProof asks the omniledger service for a Merkle proof that id is
+// present in the collection for scID (as returned in a SearchResult's
+// Block field), so a caller can verify a logged event trustlessly
+// instead of taking Search's answer on faith.
+func (c *Client) GetProof(scID skipchain.SkipBlockID, id LogID) (*omniledger.GetProofResponse, error) {
+	return omniledger.NewClient(c.roster, scID).GetProof(id)
+}
+
 func makeTx(msgs []Event, darcID darc.ID, signers []*darc.Signer) (*omniledger.ClientTransaction, error) {
 	// We need the identity part of the signatures before
 	// calling ToDarcRequest() below, because the identities
@@ -0,0 +1,56 @@
+package eventlog
+
+import (
+	"errors"
+
+	"github.com/dedis/protobuf"
+	omniledger "github.com/dedis/student_18_omniledger/omniledger/service"
+
+	"gopkg.in/dedis/cothority.v2/skipchain"
+)
+
+// eventlogContract is the omniledger Contract that governs "eventlog"
+// objects: spawning one logs an Event and indexes it for Search. Events
+// are append-only, so Invoke and Delete are not supported.
+type eventlogContract struct {
+	service *Service
+}
+
+func (c *eventlogContract) Spawn(ctx omniledger.Context, args []omniledger.Argument) (omniledger.StateChanges, error) {
+	var eventBuf []byte
+	for _, a := range args {
+		if a.Name == "event" {
+			eventBuf = a.Value
+		}
+	}
+	if eventBuf == nil {
+		return nil, errors.New("no event argument provided")
+	}
+	var event Event
+	if err := protobuf.Decode(eventBuf, &event); err != nil {
+		return nil, err
+	}
+
+	key := ctx.Instruction.ObjectID.Slice()
+	// ctx.CDB is keyed by the skipchain it belongs to, so its bucket name
+	// doubles as that skipchain's ID.
+	scID := skipchain.SkipBlockID(ctx.CDB.BucketName())
+	if err := c.service.indexEvent(event, key, scID); err != nil {
+		return nil, err
+	}
+
+	return omniledger.StateChanges{{
+		Action: omniledger.Create,
+		Key:    key,
+		Kind:   []byte(contractName),
+		Value:  eventBuf,
+	}}, nil
+}
+
+func (c *eventlogContract) Invoke(ctx omniledger.Context, command string, args []omniledger.Argument) (omniledger.StateChanges, error) {
+	return nil, errors.New("events are immutable once logged")
+}
+
+func (c *eventlogContract) Delete(ctx omniledger.Context) (omniledger.StateChanges, error) {
+	return nil, errors.New("events cannot be deleted")
+}
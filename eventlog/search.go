@@ -0,0 +1,124 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/dedis/protobuf"
+
+	bolt "github.com/coreos/bbolt"
+	"gopkg.in/dedis/cothority.v2/skipchain"
+	"gopkg.in/dedis/onet.v2/network"
+)
+
+func init() {
+	network.RegisterMessages(&SearchRequest{}, &SearchResponse{})
+}
+
+// indexRecord is what gets stored under the secondary index key; it lets
+// Search answer a query without going back to the collectionDB.
+type indexRecord struct {
+	Event Event
+	Block skipchain.SkipBlockID
+}
+
+// indexKey builds the secondary index key: the topic's length as a
+// big-endian uint32, then the topic itself, then the timestamp in
+// big-endian (so lexicographic and numeric order agree), then the
+// logID, which makes the key unique even if two events share a topic
+// and timestamp. The length prefix keeps one topic's keys from being a
+// byte-prefix of another's (e.g. "foo" vs. "foobar"), which would
+// otherwise confuse the prefix matching in Search.
+//
+// The lexicographic/numeric agreement assumes non-negative timestamps:
+// a negative timestamp wraps to a huge uint64 when cast, and would sort
+// after positive ones instead of before.
+
+func indexKey(topic string, timestamp int64, logID []byte) []byte {
+	key := make([]byte, 0, 4+len(topic)+8+len(logID))
+	var topicLen [4]byte
+	binary.BigEndian.PutUint32(topicLen[:], uint32(len(topic)))
+	key = append(key, topicLen[:]...)
+	key = append(key, []byte(topic)...)
+	var tsBuf [8]byte
+	binary.BigEndian.PutUint64(tsBuf[:], uint64(timestamp))
+	key = append(key, tsBuf[:]...)
+	key = append(key, logID...)
+	return key
+}
+
+// indexEvent records event under its (topic, timestamp) key so that
+// Search can later find it.
+func (s *Service) indexEvent(event Event, logID []byte, block skipchain.SkipBlockID) error {
+	value, err := protobuf.Encode(&indexRecord{Event: event, Block: block})
+	if err != nil {
+		return err
+	}
+	return s.storage.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexBucket).Put(indexKey(event.Topic, event.Timestamp, logID), value)
+	})
+}
+
+// SearchRequest asks for events on Topic with From <= Timestamp <= To,
+// in timestamp order, up to Limit results (0 means no limit).
+type SearchRequest struct {
+	Topic string
+	From  int64
+	To    int64
+	Limit int
+}
+
+// SearchResult is a single match returned by Search.
+type SearchResult struct {
+	ID    LogID
+	Event Event
+	Block skipchain.SkipBlockID
+}
+
+// SearchResponse is the reply to SearchRequest.
+type SearchResponse struct {
+	Events []SearchResult
+	// Truncated is true if there were more matches than Limit allowed
+	// for.
+	Truncated bool
+}
+
+// Search answers req by scanning the secondary (topic, timestamp) index
+// with a bolt cursor, starting at the first entry at or after
+// (req.Topic, req.From) and stopping at the first entry past
+// (req.Topic, req.To) or once req.Limit results have been collected.
+func (s *Service) Search(req *SearchRequest) (*SearchResponse, error) {
+	resp := &SearchResponse{}
+	err := s.storage.View(func(tx *bolt.Tx) error {
+		cur := tx.Bucket(indexBucket).Cursor()
+		prefix := indexKey(req.Topic, 0, nil)[:4+len(req.Topic)]
+		upper := indexKey(req.Topic, req.To, nil)
+		for k, v := cur.Seek(indexKey(req.Topic, req.From, nil)); k != nil; k, v = cur.Next() {
+			if len(k) < len(prefix) || !bytes.Equal(k[:len(prefix)], prefix) {
+				break
+			}
+			if bytes.Compare(k[:len(upper)], upper) > 0 {
+				break
+			}
+			if req.Limit > 0 && len(resp.Events) >= req.Limit {
+				resp.Truncated = true
+				break
+			}
+			var rec indexRecord
+			if err := protobuf.Decode(v, &rec); err != nil {
+				return err
+			}
+			logID := append([]byte{}, k[len(prefix)+8:]...)
+			resp.Events = append(resp.Events, SearchResult{
+				ID:    logID,
+				Event: rec.Event,
+				Block: rec.Block,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,79 @@
+package eventlog
+
+import (
+	"errors"
+
+	bolt "github.com/coreos/bbolt"
+	omniledger "github.com/dedis/student_18_omniledger/omniledger/service"
+
+	"gopkg.in/dedis/cothority.v2/skipchain"
+	"gopkg.in/dedis/onet.v2"
+	"gopkg.in/dedis/onet.v2/log"
+)
+
+// indexBucket holds the secondary (topic, timestamp) -> event index used
+// by Search.
+var indexBucket = []byte("eventlog_index")
+
+// Service logs events on an omniledger skipchain and lets clients search
+// them back by topic and time range.
+type Service struct {
+	*onet.ServiceProcessor
+
+	storage *bolt.DB
+}
+
+// Init sets up a fresh event logging skipchain for req.Roster. Its
+// genesis block carries only the roster: req.Owner is not stored, and
+// logged Instructions are not checked against it.
+func (s *Service) Init(req *InitRequest) (*InitResponse, error) {
+	d := &omniledger.Data{Roster: &req.Roster}
+	sb, err := skipchain.NewClient().CreateGenesisBlock(&req.Roster, skipchain.VerifyBase, d)
+	if err != nil {
+		return nil, err
+	}
+	return &InitResponse{ID: sb.Hash}, nil
+}
+
+// Log applies the Instructions carried in req.Transaction to the
+// collectionDB of req.SkipchainID, via the omniledger service's Contract
+// dispatch, then returns.
+func (s *Service) Log(req *LogRequest) (*LogResponse, error) {
+	scs := s.Context.Service(omniledger.ServiceName)
+	if scs == nil {
+		return nil, errors.New("didn't find the omniledger service")
+	}
+	omni, ok := scs.(*omniledger.Service)
+	if !ok {
+		return nil, errors.New("omniledger service is of the wrong type")
+	}
+	if _, err := omni.ApplyTransaction(req.SkipchainID, req.Transaction); err != nil {
+		return nil, err
+	}
+	return &LogResponse{}, nil
+}
+
+func newService(c *onet.Context) (onet.Service, error) {
+	s := &Service{
+		ServiceProcessor: onet.NewServiceProcessor(c),
+		storage:          c.DB(),
+	}
+	if err := s.storage.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(indexBucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.RegisterHandlers(s.Init, s.Log, s.Search); err != nil {
+		log.Error("couldn't register handlers:", err)
+		return nil, err
+	}
+	if err := omniledger.RegisterContract(c, contractName, &eventlogContract{service: s}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func init() {
+	onet.RegisterNewService(ServiceName, newService)
+}
@@ -0,0 +1,51 @@
+package eventlog
+
+import (
+	"github.com/dedis/student_18_omniledger/omniledger/darc"
+	omniledger "github.com/dedis/student_18_omniledger/omniledger/service"
+
+	"gopkg.in/dedis/cothority.v2/skipchain"
+	"gopkg.in/dedis/onet.v2"
+	"gopkg.in/dedis/onet.v2/network"
+)
+
+// ServiceName is the name to refer to the eventlog service.
+const ServiceName = "EventLog"
+
+// contractName is the omniledger contract kind that logged events are
+// stored under.
+const contractName = "eventlog"
+
+func init() {
+	network.RegisterMessages(&InitRequest{}, &InitResponse{},
+		&LogRequest{}, &LogResponse{}, &Event{})
+}
+
+// Event is a single entry in the log.
+type Event struct {
+	Timestamp int64
+	Topic     string
+	Content   string
+}
+
+// InitRequest sets up a fresh event logging skipchain for Roster. The
+// service does not store Owner or check logged Instructions against it.
+type InitRequest struct {
+	Owner  darc.Darc
+	Roster onet.Roster
+}
+
+// InitResponse is the reply to InitRequest.
+type InitResponse struct {
+	ID skipchain.SkipBlockID
+}
+
+// LogRequest asks the service to log the events carried in Transaction.
+type LogRequest struct {
+	SkipchainID skipchain.SkipBlockID
+	Transaction omniledger.ClientTransaction
+}
+
+// LogResponse is the reply to LogRequest.
+type LogResponse struct {
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/dedis/student_18_omniledger/omniledger/collection"
+	"gopkg.in/dedis/cothority.v2"
+	"gopkg.in/dedis/cothority.v2/skipchain"
+	"gopkg.in/dedis/onet.v2"
+	"gopkg.in/dedis/onet.v2/network"
+)
+
+func init() {
+	network.RegisterMessages(&DownloadState{}, &DownloadStateResponse{})
+}
+
+// downloadSessionTimeout is how long an idle download session is kept
+// open before its bolt.Tx is rolled back and the snapshot is discarded.
+const downloadSessionTimeout = time.Minute
+
+// KeyValueKind is a single entry of the collectionDB, as streamed by
+// DownloadState.
+type KeyValueKind struct {
+	Key   []byte
+	Value []byte
+	Kind  []byte
+}
+
+// DownloadState asks a conode for up to Length key/value/kind triples of
+// the collectionDB for ByzCoinID, starting at bolt-cursor position Start.
+// Nonce is empty for the first page of a catch-up; the server then
+// returns the Nonce identifying the snapshot so that subsequent pages
+// see a consistent view of the collectionDB, even if new blocks are
+// added in the meantime.
+type DownloadState struct {
+	ByzCoinID skipchain.SkipBlockID
+	Nonce     []byte
+	Start     int
+	Length    int
+}
+
+// DownloadStateResponse is the reply to DownloadState. The caller should
+// keep calling DownloadState, passing Nonce back and advancing Start by
+// len(KeyValues), until it receives fewer than Length entries.
+type DownloadStateResponse struct {
+	Nonce     []byte
+	KeyValues []KeyValueKind
+}
+
+// downloadSession pins a read-only bolt.Tx so that every page of a given
+// catch-up sees the same snapshot of the collectionDB.
+type downloadSession struct {
+	tx      *bolt.Tx
+	expires time.Time
+}
+
+// downloadSessions holds the open snapshot sessions, keyed by nonce.
+type downloadSessions struct {
+	sync.Mutex
+	byNonce map[string]*downloadSession
+}
+
+func newDownloadSessions() *downloadSessions {
+	return &downloadSessions{byNonce: make(map[string]*downloadSession)}
+}
+
+// evictExpired rolls back and forgets every session whose timeout has
+// passed. It is called opportunistically whenever a DownloadState
+// request comes in, so no background goroutine is needed.
+func (d *downloadSessions) evictExpired() {
+	now := time.Now()
+	for nonce, sess := range d.byNonce {
+		if now.After(sess.expires) {
+			sess.tx.Rollback()
+			delete(d.byNonce, nonce)
+		}
+	}
+}
+
+// DownloadState serves one page of the collectionDB for req.ByzCoinID.
+func (s *Service) DownloadState(req *DownloadState) (*DownloadStateResponse, error) {
+	s.downloads.Lock()
+	defer s.downloads.Unlock()
+	s.downloads.evictExpired()
+
+	nonce := string(req.Nonce)
+	sess, ok := s.downloads.byNonce[nonce]
+	if !ok {
+		cdb := s.getCollection(req.ByzCoinID)
+		tx, err := cdb.db.Begin(false)
+		if err != nil {
+			return nil, err
+		}
+		nb := make([]byte, 16)
+		if _, err := rand.Read(nb); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		sess = &downloadSession{tx: tx}
+		nonce = string(nb)
+		s.downloads.byNonce[nonce] = sess
+	}
+	sess.expires = time.Now().Add(downloadSessionTimeout)
+
+	cdb := s.getCollection(req.ByzCoinID)
+	bucket := sess.tx.Bucket(cdb.bucketName)
+	cur := bucket.Cursor()
+
+	// The bucket also holds one kindKey(key) row per real entry (see
+	// setValue), so those have to be skipped here: they are not
+	// themselves collectionDB entries.
+	next := func(k, v []byte) ([]byte, []byte) {
+		for k != nil && bytes.HasSuffix(k, kindKey(nil)) {
+			k, v = cur.Next()
+		}
+		return k, v
+	}
+
+	kvs := make([]KeyValueKind, 0, req.Length)
+	k, v := next(cur.First())
+	for i := 0; k != nil && i < req.Start; i++ {
+		k, v = next(cur.Next())
+	}
+	for ; k != nil && len(kvs) < req.Length; k, v = next(cur.Next()) {
+		// Kind is read from the same pinned bucket as Key/Value, not from
+		// cdb.GetValueKind, so that a page reflects the snapshot the
+		// cursor is iterating rather than whatever the live collection
+		// has become in the meantime.
+		kind := bucket.Get(kindKey(k))
+		kvs = append(kvs, KeyValueKind{
+			Key:   append([]byte{}, k...),
+			Value: append([]byte{}, v...),
+			Kind:  append([]byte{}, kind...),
+		})
+	}
+
+	if len(kvs) < req.Length {
+		sess.tx.Rollback()
+		delete(s.downloads.byNonce, nonce)
+	}
+
+	return &DownloadStateResponse{
+		Nonce:     []byte(nonce),
+		KeyValues: kvs,
+	}, nil
+}
+
+// Client talks to the omniledger service.
+type Client struct {
+	*onet.Client
+	Roster *onet.Roster
+	ID     skipchain.SkipBlockID
+}
+
+// NewClient creates a new client to talk to the omniledger service
+// maintaining the skipchain identified by id.
+func NewClient(r *onet.Roster, id skipchain.SkipBlockID) *Client {
+	return &Client{
+		Client: onet.NewClient(cothority.Suite, ServiceName),
+		Roster: r,
+		ID:     id,
+	}
+}
+
+// CatchUp rebuilds coll by downloading the collectionDB state in pages
+// from a node that already has it, then checks the rebuilt root against
+// the MerkleRoot of the latest skipblock before returning.
+func (c *Client) CatchUp(coll *collection.Collection) error {
+	const pageSize = 1000
+	var nonce []byte
+	start := 0
+	for {
+		req := &DownloadState{
+			ByzCoinID: c.ID,
+			Nonce:     nonce,
+			Start:     start,
+			Length:    pageSize,
+		}
+		reply := &DownloadStateResponse{}
+		if err := c.SendProtobuf(c.Roster.List[0], req, reply); err != nil {
+			return err
+		}
+		for _, kv := range reply.KeyValues {
+			if err := coll.Add(kv.Key, kv.Value, kv.Kind); err != nil {
+				return err
+			}
+		}
+		nonce = reply.Nonce
+		start += len(reply.KeyValues)
+		if len(reply.KeyValues) < pageSize {
+			break
+		}
+	}
+
+	sb, err := c.latestBlock()
+	if err != nil {
+		return err
+	}
+	data, err := dataFromSkipBlock(sb)
+	if err != nil {
+		return err
+	}
+	if string(coll.GetRoot()) != string(data.MerkleRoot) {
+		return errors.New("rebuilt collection root does not match the latest skipblock's MerkleRoot")
+	}
+	return nil
+}
+
+// latestBlock fetches the latest skipblock on c's skipchain.
+func (c *Client) latestBlock() (*skipchain.SkipBlock, error) {
+	sc := skipchain.NewClient()
+	reply, err := sc.GetUpdateChain(c.Roster, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.Update) == 0 {
+		return nil, errors.New("empty update chain")
+	}
+	return reply.Update[len(reply.Update)-1], nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/dedis/cothority.v2/skipchain"
+)
+
+// StateChange describes a single modification that a Contract wants
+// applied to the collectionDB once its Instruction has been accepted.
+type StateChange struct {
+	Action Action
+	Key    []byte
+	Kind   []byte
+	Value  []byte
+}
+
+// StateChanges is the list of modifications produced by a single
+// Contract call; they are applied to the collectionDB together, in
+// order.
+type StateChanges []StateChange
+
+// Context carries everything a Contract needs to process one
+// Instruction: read access to the collectionDB as it stood before this
+// Instruction (earlier Instructions in the same block may already have
+// been applied), and the Instruction itself.
+type Context struct {
+	CDB         *collectionDB
+	Instruction Instruction
+}
+
+// Contract is implemented by anything that wants to govern objects of a
+// given kind: it decides what StateChanges result from spawning a new
+// object, invoking a command on an existing one, or deleting it.
+type Contract interface {
+	Spawn(ctx Context, args []Argument) (StateChanges, error)
+	Invoke(ctx Context, command string, args []Argument) (StateChanges, error)
+	Delete(ctx Context) (StateChanges, error)
+}
+
+// RegisterContract stores c under name in the omniledger service found
+// through s, so the block processor will dispatch to it for every
+// Instruction on an object of that kind.
+// GetService makes it possible to give either an onet.Context or
+// onet.Server to RegisterContract.
+func RegisterContract(s skipchain.GetService, name string, c Contract) error {
+	scs := s.Service(ServiceName)
+	if scs == nil {
+		return errors.New("Didn't find our service: " + ServiceName)
+	}
+	return scs.(*Service).registerContract(name, c)
+}
+
+func (s *Service) registerContract(name string, c Contract) error {
+	s.contractsMutex.Lock()
+	defer s.contractsMutex.Unlock()
+	s.contracts[name] = c
+	return nil
+}
+
+// legacyContract adapts an old-style OmniledgerVerifier to the Contract
+// interface, so that call sites using RegisterVerification keep working
+// unchanged: it is Invoke-only, since the old model had no notion of
+// Spawn or Delete.
+type legacyContract struct {
+	verify OmniledgerVerifier
+}
+
+func (l *legacyContract) Spawn(ctx Context, args []Argument) (StateChanges, error) {
+	return nil, errors.New("legacy verifiers do not support Spawn")
+}
+
+func (l *legacyContract) Invoke(ctx Context, command string, args []Argument) (StateChanges, error) {
+	key := ctx.Instruction.ObjectID.Slice()
+	_, kind, err := ctx.CDB.GetValueKind(key)
+	if err != nil {
+		return nil, err
+	}
+	var value []byte
+	for _, a := range args {
+		if a.Name == "value" {
+			value = a.Value
+		}
+	}
+	t := &Transaction{
+		Action: Update,
+		Key:    key,
+		Kind:   kind,
+		Value:  value,
+	}
+	if !l.verify(ctx.CDB, t) {
+		return nil, fmt.Errorf("legacy verifier for kind %q rejected the instruction", kind)
+	}
+	return StateChanges{{Action: Update, Key: key, Kind: kind, Value: value}}, nil
+}
+
+func (l *legacyContract) Delete(ctx Context) (StateChanges, error) {
+	return nil, errors.New("legacy verifiers do not support Delete")
+}
+
+// executeInstruction dispatches instr to the Contract registered for the
+// kind of object it targets (for Spawn, the kind is the brand-new
+// ContractID; otherwise it is looked up via GetValueKind on the
+// instruction's ObjectID), and returns the resulting StateChanges.
+func (s *Service) executeInstruction(cdb *collectionDB, instr Instruction) (StateChanges, error) {
+	var contractID string
+	switch {
+	case instr.Spawn != nil:
+		contractID = instr.Spawn.ContractID
+	case instr.Invoke != nil || instr.Delete != nil:
+		_, kind, err := cdb.GetValueKind(instr.ObjectID.Slice())
+		if err != nil {
+			return nil, err
+		}
+		contractID = string(kind)
+	default:
+		return nil, errors.New("instruction has neither Spawn, Invoke nor Delete set")
+	}
+
+	s.contractsMutex.Lock()
+	c, ok := s.contracts[contractID]
+	s.contractsMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no contract registered for kind %q", contractID)
+	}
+
+	ctx := Context{CDB: cdb, Instruction: instr}
+	switch {
+	case instr.Spawn != nil:
+		return c.Spawn(ctx, instr.Spawn.Args)
+	case instr.Invoke != nil:
+		return c.Invoke(ctx, instr.Invoke.Command, instr.Invoke.Args)
+	default:
+		return c.Delete(ctx)
+	}
+}
+
+// ApplyTransaction executes every Instruction in tx against the
+// collectionDB for the skipchain identified by id, in order, committing
+// each Instruction's StateChanges before moving on to the next so that
+// later Instructions in the same ClientTransaction see earlier ones'
+// effects. If an Instruction fails, the StateChanges of the Instructions
+// before it stay applied. It is the single place where Instructions, as
+// opposed to the legacy Transaction model, actually get applied; it does
+// not add a block to the skipchain.
+func (s *Service) ApplyTransaction(id skipchain.SkipBlockID, tx ClientTransaction) (StateChanges, error) {
+	cdb := s.getCollection(id)
+	var all StateChanges
+	for _, instr := range tx.Instructions {
+		scs, err := s.executeInstruction(cdb, instr)
+		if err != nil {
+			return nil, err
+		}
+		if err := cdb.applyStateChanges(scs); err != nil {
+			return nil, err
+		}
+		all = append(all, scs...)
+	}
+	return all, nil
+}
+
+// applyStateChanges writes every StateChange to c, in order.
+func (c *collectionDB) applyStateChanges(scs StateChanges) error {
+	for _, sc := range scs {
+		switch sc.Action {
+		case Create, Update:
+			if err := c.setValue(sc.Key, sc.Value, sc.Kind); err != nil {
+				return err
+			}
+		case Remove:
+			if err := c.removeValue(sc.Key); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid action: %v", sc.Action)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+
+	"github.com/dedis/student_18_omniledger/omniledger/darc"
+	"gopkg.in/dedis/onet.v2/network"
+)
+
+func init() {
+	network.RegisterMessages(&ClientTransaction{}, &Instruction{}, &Spawn{},
+		&Invoke{}, &Delete{}, &Argument{})
+}
+
+// Nonce is a random 32-byte value, used as Instruction.Nonce and as
+// ObjectID.InstanceID. No code here rejects an Instruction whose Nonce
+// has been seen before.
+type Nonce [32]byte
+
+// GenNonce returns a fresh, random Nonce.
+func GenNonce() Nonce {
+	var n Nonce
+	if _, err := rand.Read(n[:]); err != nil {
+		panic("couldn't get randomness: " + err.Error())
+	}
+	return n
+}
+
+// ObjectID uniquely identifies an object living under a Darc: DarcID
+// names the Darc that governs it, InstanceID distinguishes objects
+// spawned under the same Darc.
+type ObjectID struct {
+	DarcID     darc.ID
+	InstanceID Nonce
+}
+
+// Slice returns the concatenation of DarcID and InstanceID, which is
+// what is actually used as the key in the collectionDB.
+func (o ObjectID) Slice() []byte {
+	return append(append([]byte{}, o.DarcID...), o.InstanceID[:]...)
+}
+
+// Argument is a named parameter passed to a contract's Spawn or Invoke.
+type Argument struct {
+	Name  string
+	Value []byte
+}
+
+// Spawn is the part of an Instruction that creates a new object of kind
+// ContractID.
+type Spawn struct {
+	ContractID string
+	Args       []Argument
+}
+
+// Invoke is the part of an Instruction that calls Command on an
+// already-existing object.
+type Invoke struct {
+	Command string
+	Args    []Argument
+}
+
+// Delete is the part of an Instruction that removes an already-existing
+// object.
+type Delete struct{}
+
+// Instruction is one operation on one object. Exactly one of Spawn,
+// Invoke or Delete must be set.
+type Instruction struct {
+	ObjectID ObjectID
+	Nonce    Nonce
+	Index    int
+	Length   int
+
+	Spawn  *Spawn
+	Invoke *Invoke
+	Delete *Delete
+
+	Signatures []darc.Signature
+}
+
+// ClientTransaction is a set of Instructions that ApplyTransaction
+// applies in order. It is not atomic: if one Instruction fails, the
+// changes of the Instructions before it stay applied.
+type ClientTransaction struct {
+	Instructions []Instruction
+}
+
+// action returns the Darc rule name that governs this Instruction, e.g.
+// "Spawn_eventlog" or "Invoke_evolve".
+func (instr Instruction) action() (string, error) {
+	switch {
+	case instr.Spawn != nil:
+		return "Spawn_" + instr.Spawn.ContractID, nil
+	case instr.Invoke != nil:
+		return "Invoke_" + instr.Invoke.Command, nil
+	case instr.Delete != nil:
+		return "Delete", nil
+	default:
+		return "", errors.New("instruction has neither Spawn, Invoke nor Delete set")
+	}
+}
+
+// hash returns the digest that identifies this Instruction, independent
+// of the Signatures that will be put on it.
+func (instr Instruction) hash() []byte {
+	h := sha256.New()
+	h.Write(instr.ObjectID.DarcID)
+	h.Write(instr.ObjectID.InstanceID[:])
+	h.Write(instr.Nonce[:])
+	h.Write([]byte(strconv.Itoa(instr.Index)))
+	h.Write([]byte(strconv.Itoa(instr.Length)))
+	switch {
+	case instr.Spawn != nil:
+		h.Write([]byte(instr.Spawn.ContractID))
+		for _, a := range instr.Spawn.Args {
+			h.Write([]byte(a.Name))
+			h.Write(a.Value)
+		}
+	case instr.Invoke != nil:
+		h.Write([]byte(instr.Invoke.Command))
+		for _, a := range instr.Invoke.Args {
+			h.Write([]byte(a.Name))
+			h.Write(a.Value)
+		}
+	}
+	return h.Sum(nil)
+}
+
+// DarcRequest is what a Darc identity signs in order to authorize an
+// Instruction. ApplyTransaction does not check the signatures on an
+// Instruction against the rules of the Darc identified by
+// ObjectID.DarcID.
+type DarcRequest struct {
+	DarcID     darc.ID
+	Action     string
+	Digest     []byte
+	Identities []darc.Identity
+}
+
+// Hash returns the digest that signers actually sign.
+func (r *DarcRequest) Hash() []byte {
+	h := sha256.New()
+	h.Write(r.DarcID)
+	h.Write([]byte(r.Action))
+	h.Write(r.Digest)
+	for _, id := range r.Identities {
+		h.Write([]byte(id.String()))
+	}
+	return h.Sum(nil)
+}
+
+// ToDarcRequest builds the DarcRequest that signers must sign over to
+// authorize instr.
+func (instr Instruction) ToDarcRequest() (*DarcRequest, error) {
+	action, err := instr.action()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]darc.Identity, len(instr.Signatures))
+	for i, sig := range instr.Signatures {
+		ids[i] = sig.Signer
+	}
+	return &DarcRequest{
+		DarcID:     instr.ObjectID.DarcID,
+		Action:     action,
+		Digest:     instr.hash(),
+		Identities: ids,
+	}, nil
+}
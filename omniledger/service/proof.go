@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/dedis/student_18_omniledger/omniledger/collection"
+	"gopkg.in/dedis/cothority.v2"
+	"gopkg.in/dedis/cothority.v2/skipchain"
+	"gopkg.in/dedis/onet.v2"
+	"gopkg.in/dedis/onet.v2/network"
+)
+
+func init() {
+	network.RegisterMessages(&GetProof{}, &GetProofResponse{})
+}
+
+// GetProof is a request for a Merkle inclusion (or absence) proof of Key
+// under the collection as of the latest block of the skipchain whose
+// genesis ID is ID.
+type GetProof struct {
+	ID  skipchain.SkipBlockID
+	Key []byte
+}
+
+// GetProofResponse is the reply to GetProof. Proof.Verify checks the
+// returned record against Latest.Data's MerkleRoot, and Links lets the
+// caller tie Latest back to the genesis block, one forward-link at a
+// time, without having to trust the conode that answered the request.
+type GetProofResponse struct {
+	Proof  Proof
+	Latest skipchain.SkipBlock
+	Links  []*skipchain.ForwardLink
+}
+
+// Proof is a client-facing collection proof: the path of a single key
+// through the Merkle trie, either showing the key's value and kind
+// (inclusion) or showing enough of the trie to prove the key is absent.
+type Proof struct {
+	// Record is the raw proof produced by collection.Collection.Get,
+	// which carries the path hashes needed to recompute the root.
+	Record collection.Proof
+}
+
+// Verify recomputes the Merkle root implied by p.Record and checks it
+// against root. It also checks that the proof is actually about
+// expectedKey, so that a server cannot answer a request for one key with
+// a valid proof for another. It returns nil if the proof checks out.
+func (p Proof) Verify(root []byte, expectedKey []byte) error {
+	if !bytes.Equal(p.Record.Key(), expectedKey) {
+		return errors.New("proof is for the wrong key")
+	}
+	if !p.Record.Match() {
+		return errors.New("proof is not internally consistent")
+	}
+	if !bytes.Equal(p.Record.GetRoot(), root) {
+		return errors.New("proof root does not match the block's MerkleRoot")
+	}
+	return nil
+}
+
+// Values returns the stored value and kind for the key this Proof is
+// about, or an error if the key is absent (i.e. this is an absence
+// proof).
+func (p Proof) Values() (value, kind []byte, err error) {
+	vals, err := p.Record.Values()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(vals) < 2 {
+		return nil, nil, errors.New("key is not present in this proof")
+	}
+	var ok bool
+	value, ok = vals[0].([]byte)
+	if !ok {
+		return nil, nil, errors.New("the value is not of type []byte")
+	}
+	kind, ok = vals[1].([]byte)
+	if !ok {
+		return nil, nil, errors.New("the kind is not of type []byte")
+	}
+	return value, kind, nil
+}
+
+// GetProof looks up req.Key in the collection for req.ID's skipchain as
+// of the latest block, and returns the proof together with that
+// skipblock and the forward-link chain from genesis, so a light client
+// can verify the whole thing starting only from a genesis block it
+// already trusts. req.ID is the chain's fixed genesis ID, not a
+// specific block to prove against, so Latest has to be resolved via the
+// update chain rather than looked up directly.
+func (s *Service) GetProof(req *GetProof) (*GetProofResponse, error) {
+	local := &onet.Roster{List: []*network.ServerIdentity{s.ServerIdentity()}}
+	reply, err := s.skipchainClient().GetUpdateChain(local, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.Update) == 0 {
+		return nil, errors.New("empty update chain")
+	}
+	sb := reply.Update[len(reply.Update)-1]
+	cdb := s.getCollection(sb.SkipChainID())
+	rec, err := cdb.coll.Get(req.Key).Record()
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.forwardLinks(sb)
+	if err != nil {
+		return nil, err
+	}
+	return &GetProofResponse{
+		Proof:  Proof{Record: rec},
+		Latest: *sb,
+		Links:  links,
+	}, nil
+}
+
+// GetProof asks the conode at c.Roster.List[0] for a Merkle proof of key
+// under the collection for c.ID, so that a caller can read state
+// trustlessly: the returned Proof.Verify checks the proof against
+// reply.Latest's MerkleRoot without the caller needing to trust the
+// conode that answered.
+func (c *Client) GetProof(key []byte) (*GetProofResponse, error) {
+	req := &GetProof{ID: c.ID, Key: key}
+	reply := &GetProofResponse{}
+	if err := c.SendProtobuf(c.Roster.List[0], req, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// forwardLinks walks the skipchain from the genesis block up to (and
+// including) sb, returning the forward-links in genesis-to-sb order, so
+// that a verifier can check them one by one starting from the genesis
+// block it already trusts.
+func (s *Service) forwardLinks(sb *skipchain.SkipBlock) ([]*skipchain.ForwardLink, error) {
+	reply, err := s.skipchainClient().GetUpdateChain(sb.Roster, sb.SkipChainID())
+	if err != nil {
+		return nil, err
+	}
+	var links []*skipchain.ForwardLink
+	for _, b := range reply.Update {
+		if len(b.ForwardLink) == 0 {
+			break
+		}
+		links = append(links, b.ForwardLink[0])
+		if b.Hash.Equal(sb.Hash) {
+			break
+		}
+	}
+	return links, nil
+}
+
+// skipchainClient returns a fresh skipchain client. It is not kept on
+// Service because onet.Client values are cheap and safe to use
+// concurrently.
+func (s *Service) skipchainClient() *skipchain.Client {
+	return skipchain.NewClient()
+}
+
+// dataFromSkipBlock decodes the omniledger Data stored in sb.
+func dataFromSkipBlock(sb *skipchain.SkipBlock) (*Data, error) {
+	_, d, err := network.Unmarshal(sb.Data, cothority.Suite)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := d.(*Data)
+	if !ok {
+		return nil, errors.New("data of wrong type")
+	}
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"sync"
+
+	bolt "github.com/coreos/bbolt"
+	"gopkg.in/dedis/cothority.v2/skipchain"
+	"gopkg.in/dedis/onet.v2"
+	"gopkg.in/dedis/onet.v2/log"
+)
+
+// ServiceName is the name to refer to the omniledger service from another
+// service or application, e.g. when calling onet.Context.Service or
+// skipchain.GetService.Service.
+const ServiceName = "OmniLedger"
+
+// Service keeps one collectionDB per skipchain it has been asked to
+// maintain, and applies the Instructions of each ClientTransaction it is
+// given to the matching collectionDB (see ApplyTransaction).
+type Service struct {
+	*onet.ServiceProcessor
+
+	storage *bolt.DB
+
+	collectionsMutex sync.Mutex
+	collections      map[string]*collectionDB
+
+	contractsMutex sync.Mutex
+	contracts      map[string]Contract
+
+	downloads *downloadSessions
+}
+
+// getCollection returns the collectionDB responsible for the skipchain
+// identified by id, creating it (and its bolt bucket) on first use.
+func (s *Service) getCollection(id skipchain.SkipBlockID) *collectionDB {
+	s.collectionsMutex.Lock()
+	defer s.collectionsMutex.Unlock()
+	idStr := string(id)
+	cdb, ok := s.collections[idStr]
+	if !ok {
+		cdb = newCollectionDB(s.storage, []byte(idStr))
+		s.collections[idStr] = cdb
+	}
+	return cdb
+}
+
+// registerVerification registers f as a legacy, Invoke-only Contract
+// under kind, so that Instructions targeting objects of that kind are
+// dispatched to f just like before the Contract/Action framework existed.
+func (s *Service) registerVerification(kind string, f OmniledgerVerifier) error {
+	return s.registerContract(kind, &legacyContract{verify: f})
+}
+
+func newService(c *onet.Context) (onet.Service, error) {
+	s := &Service{
+		ServiceProcessor: onet.NewServiceProcessor(c),
+		storage:          c.DB(),
+		collections:      make(map[string]*collectionDB),
+		contracts:        make(map[string]Contract),
+		downloads:        newDownloadSessions(),
+	}
+	if err := s.RegisterHandlers(s.GetProof, s.DownloadState); err != nil {
+		log.Error("couldn't register handlers:", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+func init() {
+	onet.RegisterNewService(ServiceName, newService)
+}
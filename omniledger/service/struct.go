@@ -69,16 +69,36 @@ func (c *collectionDB) loadAll() {
 }
 
 func (c *collectionDB) Store(t *Transaction) error {
-	c.coll.Add(t.Key, t.Value, t.Kind)
+	return c.setValue(t.Key, t.Value, t.Kind)
+}
+
+// kindKeyPad is the number of zero-padding bytes kindKey inserts between
+// key and the literal "kind" marker.
+const kindKeyPad = 4
+
+// kindSuffix is the literal marker kindKey appends after the padding.
+var kindSuffix = []byte("kind")
+
+// kindKey returns the bolt key under which setValue stores key's kind,
+// so that removeValue and DownloadState can recognise (and skip) these
+// metadata rows when walking the bucket directly.
+func kindKey(key []byte) []byte {
+	kk := make([]byte, len(key)+kindKeyPad)
+	copy(kk, key)
+	return append(kk, kindSuffix...)
+}
+
+// setValue records the key/value/kind triple both in the in-memory
+// Merkle collection and in the on-disk bolt bucket. loadAll does not read
+// the kind back from the kindKey(key) row written here.
+func (c *collectionDB) setValue(key, value, kind []byte) error {
+	c.coll.Add(key, value, kind)
 	err := c.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(c.bucketName))
-		if err := bucket.Put(t.Key, t.Value); err != nil {
+		if err := bucket.Put(key, value); err != nil {
 			return err
 		}
-		keykind := make([]byte, len(t.Key)+4)
-		copy(keykind, t.Key)
-		keykind = append(keykind, []byte("kind")...)
-		if err := bucket.Put(keykind, t.Kind); err != nil {
+		if err := bucket.Put(kindKey(key), kind); err != nil {
 			return err
 		}
 		return nil
@@ -86,6 +106,21 @@ func (c *collectionDB) Store(t *Transaction) error {
 	return err
 }
 
+// removeValue deletes key from both the in-memory Merkle collection and
+// the on-disk bolt bucket.
+func (c *collectionDB) removeValue(key []byte) error {
+	if err := c.coll.Remove(key); err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.bucketName))
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+		return bucket.Delete(kindKey(key))
+	})
+}
+
 func (c *collectionDB) GetValueKind(key []byte) (value, kind []byte, err error) {
 	proof, err := c.coll.Get(key).Record()
 	if err != nil {
@@ -117,6 +152,12 @@ func (c *collectionDB) RootHash() []byte {
 	return c.coll.GetRoot()
 }
 
+// BucketName returns the name this collectionDB is stored under, which
+// is also the ID of the skipchain it belongs to.
+func (c *collectionDB) BucketName() []byte {
+	return c.bucketName
+}
+
 // tryHash returns the merkle root of the collection as if the key value pairs
 // in the transactions had been added, without actually adding it.
 func (c *collectionDB) tryHash(ts []Transaction) (mr []byte, rerr error) {